@@ -0,0 +1,137 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diffutil
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+// ComputeAppliedDrift re-fetches info's live state and diffs it against
+// desired -- the manifest Helm applied for this object before the fetch --
+// after stripping server-managed fields and pruning away any field the live
+// object carries that desired never set. That pruning is what keeps this
+// check honest: the API server defaults dozens of fields a manifest never
+// mentions (a Deployment's rollout strategy, a Service's clusterIP, ...),
+// and without restricting the comparison to fields Helm actually set, every
+// rollout would show as "drifted". A non-empty result means a field Helm
+// did set no longer matches live -- e.g. an admission webhook, a mutating
+// controller, or a manual edit changed or removed it.
+//
+// info.Object is overwritten with the freshly fetched live object as a
+// side effect of the underlying info.Get() call, so desired must be
+// captured by the caller beforehand.
+func ComputeAppliedDrift(info *resource.Info, desired runtime.Object) (ResourceDiff, error) {
+	desiredObj, err := ToUnstructuredMap(desired)
+	if err != nil {
+		return ResourceDiff{}, err
+	}
+	desiredLines, err := marshalLines(&unstructured.Unstructured{Object: desiredObj})
+	if err != nil {
+		return ResourceDiff{}, err
+	}
+
+	if err := info.Get(); err != nil {
+		return ResourceDiff{}, err
+	}
+
+	liveObj, err := ToUnstructuredMap(info.Object)
+	if err != nil {
+		return ResourceDiff{}, err
+	}
+	StripServerManagedFields(liveObj)
+	prunedLive := pruneToDesiredFields(liveObj, desiredObj)
+
+	liveLines, err := marshalLines(&unstructured.Unstructured{Object: prunedLive})
+	if err != nil {
+		return ResourceDiff{}, err
+	}
+
+	return ResourceDiff{
+		GVK:       info.Mapping.GroupVersionKind,
+		Namespace: info.Namespace,
+		Name:      info.Name,
+		Op:        OpUpdated,
+		Hunks:     groupHunks(Myers(desiredLines, liveLines)),
+	}, nil
+}
+
+// StripServerManagedFields removes fields Kubernetes populates server-side
+// so a live object can be compared against (or reapplied from) the manifest
+// that was applied to produce it.
+func StripServerManagedFields(obj map[string]interface{}) {
+	unstructured.RemoveNestedField(obj, "metadata", "managedFields")
+	unstructured.RemoveNestedField(obj, "metadata", "resourceVersion")
+	unstructured.RemoveNestedField(obj, "metadata", "uid")
+	unstructured.RemoveNestedField(obj, "metadata", "creationTimestamp")
+	unstructured.RemoveNestedField(obj, "status")
+}
+
+// ToUnstructuredMap converts a runtime.Object to its unstructured
+// map[string]interface{} form.
+func ToUnstructuredMap(obj runtime.Object) (map[string]interface{}, error) {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return u.Object, nil
+	}
+	return runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+}
+
+// pruneToDesiredFields returns a copy of live containing only the keys
+// (recursively, including list elements matched by index) that desired also
+// sets, so that fields the API server defaulted on its own don't show up as
+// drift. This is a best-effort approximation of a three-way merge: list
+// elements are compared positionally rather than by a merge key (e.g.
+// container name), so a reordered list can still read as drift.
+func pruneToDesiredFields(live, desired map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(desired))
+	for k, dv := range desired {
+		lv, ok := live[k]
+		if !ok {
+			continue
+		}
+		out[k] = pruneValueToDesired(lv, dv)
+	}
+	return out
+}
+
+func pruneValueToDesired(live, desired interface{}) interface{} {
+	if dm, ok := desired.(map[string]interface{}); ok {
+		if lm, ok := live.(map[string]interface{}); ok {
+			return pruneToDesiredFields(lm, dm)
+		}
+		return live
+	}
+	if ds, ok := desired.([]interface{}); ok {
+		if ls, ok := live.([]interface{}); ok {
+			return pruneSliceToDesired(ls, ds)
+		}
+		return live
+	}
+	return live
+}
+
+func pruneSliceToDesired(live, desired []interface{}) []interface{} {
+	out := make([]interface{}, 0, len(desired))
+	for i, dv := range desired {
+		if i >= len(live) {
+			break
+		}
+		out = append(out, pruneValueToDesired(live[i], dv))
+	}
+	return out
+}
@@ -0,0 +1,126 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diffutil
+
+// EditOp describes a single operation in an edit script produced by Myers.
+type EditOp int
+
+const (
+	// OpEqual indicates the line is unchanged between the two sequences.
+	OpEqual EditOp = iota
+	// OpDelete indicates the line is present in the old sequence only.
+	OpDelete
+	// OpInsert indicates the line is present in the new sequence only.
+	OpInsert
+)
+
+// Edit is a single line-level operation in an edit script.
+type Edit struct {
+	Op   EditOp
+	Text string
+}
+
+// Myers computes the shortest edit script turning a into b using Myers'
+// O(ND) diff algorithm (http://www.xmailserver.org/diff2.pdf). It operates
+// at line granularity, which is sufficient for diffing YAML-serialized
+// Kubernetes manifests and keeps the implementation dependency-free.
+func Myers(a, b []string) []Edit {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return nil
+	}
+
+	offset := max
+	v := make([]int, 2*max+1)
+	var trace [][]int
+
+	foundD := max
+	found := false
+
+loop:
+	for d := 0; d <= max; d++ {
+		snapshot := make([]int, len(v))
+		copy(snapshot, v)
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				foundD = d
+				break loop
+			}
+		}
+	}
+	if !found {
+		foundD = len(trace) - 1
+	}
+
+	return backtrack(a, b, trace, foundD, offset)
+}
+
+func backtrack(a, b []string, trace [][]int, d, offset int) []Edit {
+	x, y := len(a), len(b)
+	var edits []Edit
+
+	for depth := d; depth >= 0 && (x > 0 || y > 0); depth-- {
+		v := trace[depth]
+		k := x - y
+
+		var prevK int
+		if k == -depth || (k != depth && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			edits = append(edits, Edit{Op: OpEqual, Text: a[x-1]})
+			x--
+			y--
+		}
+
+		if depth > 0 {
+			if x == prevX {
+				edits = append(edits, Edit{Op: OpInsert, Text: b[y-1]})
+				y--
+			} else {
+				edits = append(edits, Edit{Op: OpDelete, Text: a[x-1]})
+				x--
+			}
+		}
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
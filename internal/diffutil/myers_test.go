@@ -0,0 +1,75 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diffutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// reconstruct replays an edit script and returns the resulting old/new
+// sequences, so tests can check correctness without depending on exactly
+// how ties between equally-short edit scripts are broken.
+func reconstruct(edits []Edit) (a, b []string) {
+	for _, e := range edits {
+		switch e.Op {
+		case OpEqual:
+			a = append(a, e.Text)
+			b = append(b, e.Text)
+		case OpDelete:
+			a = append(a, e.Text)
+		case OpInsert:
+			b = append(b, e.Text)
+		}
+	}
+	return a, b
+}
+
+func TestMyers(t *testing.T) {
+	is := assert.New(t)
+
+	tests := []struct {
+		name string
+		a, b []string
+	}{
+		{name: "identical", a: []string{"foo", "bar"}, b: []string{"foo", "bar"}},
+		{name: "append", a: []string{"foo"}, b: []string{"foo", "bar"}},
+		{name: "delete", a: []string{"foo", "bar"}, b: []string{"foo"}},
+		{name: "replace middle", a: []string{"foo", "bar", "baz"}, b: []string{"foo", "qux", "baz"}},
+		{name: "both empty", a: nil, b: nil},
+		{name: "totally different", a: []string{"a", "b", "c"}, b: []string{"x", "y"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edits := Myers(tt.a, tt.b)
+			gotA, gotB := reconstruct(edits)
+			is.Equal(tt.a, gotA, "edit script must reconstruct the old sequence")
+			is.Equal(tt.b, gotB, "edit script must reconstruct the new sequence")
+		})
+	}
+}
+
+func TestMyersIdenticalIsAllEqual(t *testing.T) {
+	is := assert.New(t)
+
+	edits := Myers([]string{"foo", "bar"}, []string{"foo", "bar"})
+	for _, e := range edits {
+		is.Equal(OpEqual, e.Op)
+	}
+}
@@ -0,0 +1,58 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diffutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	ansiRed   = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+	ansiCyan  = "\x1b[36m"
+	ansiReset = "\x1b[0m"
+)
+
+// RenderText renders diffs as a colored unified diff, in the style of
+// `diff -u`.
+func RenderText(diffs []ResourceDiff) string {
+	var b strings.Builder
+	for _, d := range diffs {
+		fmt.Fprintf(&b, "%s%s %s/%s %s%s\n", ansiCyan, d.Op, d.Namespace, d.Name, d.GVK.String(), ansiReset)
+		for _, h := range d.Hunks {
+			fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+			for _, l := range h.Lines {
+				switch l.Op {
+				case OpDelete:
+					fmt.Fprintf(&b, "%s-%s%s\n", ansiRed, l.Text, ansiReset)
+				case OpInsert:
+					fmt.Fprintf(&b, "%s+%s%s\n", ansiGreen, l.Text, ansiReset)
+				default:
+					fmt.Fprintf(&b, " %s\n", l.Text)
+				}
+			}
+		}
+	}
+	return b.String()
+}
+
+// RenderJSON renders diffs as machine-readable JSON.
+func RenderJSON(diffs []ResourceDiff) ([]byte, error) {
+	return json.MarshalIndent(diffs, "", "  ")
+}
@@ -0,0 +1,432 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diffutil
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+	"sigs.k8s.io/yaml"
+
+	"helm.sh/helm/v3/pkg/kube"
+)
+
+// contextLines is the number of unchanged lines kept around a change when
+// grouping edits into hunks, matching the conventional unified diff default.
+const contextLines = 3
+
+// Op describes what kind of change a ResourceDiff represents.
+type Op string
+
+const (
+	OpCreated Op = "Created"
+	OpUpdated Op = "Updated"
+	OpDeleted Op = "Deleted"
+)
+
+// Provenance attributes a changed hunk line to where the change came from,
+// so that callers can distinguish drift (changes introduced out-of-band,
+// e.g. by admission webhooks or manual kubectl edits) from changes Helm
+// itself is making.
+type Provenance string
+
+const (
+	// ProvenanceIntended marks a line changed by Helm (target vs. what was
+	// last applied).
+	ProvenanceIntended Provenance = "intended"
+	// ProvenanceDrift marks a line that differs from the last-applied
+	// configuration without Helm having asked for that change.
+	ProvenanceDrift Provenance = "drift"
+)
+
+// HunkLine is a single line of a unified diff hunk.
+type HunkLine struct {
+	Op         EditOp     `json:"op"`
+	Text       string     `json:"text"`
+	Provenance Provenance `json:"provenance,omitempty"`
+}
+
+// Hunk is a contiguous, context-bounded block of changed (and surrounding
+// unchanged) lines, in the style of a unified diff "@@" hunk.
+type Hunk struct {
+	OldStart int        `json:"oldStart"`
+	OldLines int        `json:"oldLines"`
+	NewStart int        `json:"newStart"`
+	NewLines int        `json:"newLines"`
+	Lines    []HunkLine `json:"lines"`
+}
+
+// ResourceDiff is the structured diff of a single Kubernetes object.
+type ResourceDiff struct {
+	GVK       schema.GroupVersionKind `json:"gvk"`
+	Namespace string                  `json:"namespace"`
+	Name      string                  `json:"name"`
+	Op        Op                      `json:"op"`
+	Hunks     []Hunk                  `json:"hunks,omitempty"`
+}
+
+// Compute produces a structured, in-process diff (no shell, no temp files)
+// for everything a kube.Result touched, using the live state captured
+// before the update (result.LiveBeforeUpdate) as the "old" side of each
+// Updated resource.
+func Compute(result *kube.Result) ([]ResourceDiff, error) {
+	var diffs []ResourceDiff
+
+	for _, info := range result.Created {
+		d, err := diffOne(info, nil, info.Object, OpCreated)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, d)
+	}
+	for _, info := range result.Updated {
+		old := result.LiveBeforeUpdate[info]
+		if old == nil {
+			return nil, fmt.Errorf("no live-before-update version of %s", infoName(info))
+		}
+		d, err := diffOne(info, old, info.Object, OpUpdated)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, d)
+	}
+	for _, info := range result.Deleted {
+		d, err := diffOne(info, info.Object, nil, OpDeleted)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, d)
+	}
+
+	return diffs, nil
+}
+
+// ComputeResourceLists produces a structured diff between two resource
+// lists that have not necessarily been applied yet (e.g. a rollback or
+// upgrade preview), pairing objects by namespace/name/GVK rather than
+// relying on a prior kube.Result classification.
+func ComputeResourceLists(current, target kube.ResourceList) ([]ResourceDiff, error) {
+	currentByKey := make(map[string]*resource.Info, len(current))
+	for _, info := range current {
+		currentByKey[infoName(info)] = info
+	}
+	targetByKey := make(map[string]*resource.Info, len(target))
+	for _, info := range target {
+		targetByKey[infoName(info)] = info
+	}
+
+	var diffs []ResourceDiff
+	for _, info := range target {
+		key := infoName(info)
+		if old, ok := currentByKey[key]; ok {
+			d, err := diffOne(info, old.Object, info.Object, OpUpdated)
+			if err != nil {
+				return nil, err
+			}
+			diffs = append(diffs, d)
+		} else {
+			d, err := diffOne(info, nil, info.Object, OpCreated)
+			if err != nil {
+				return nil, err
+			}
+			diffs = append(diffs, d)
+		}
+	}
+	for _, info := range current {
+		key := infoName(info)
+		if _, ok := targetByKey[key]; !ok {
+			d, err := diffOne(info, info.Object, nil, OpDeleted)
+			if err != nil {
+				return nil, err
+			}
+			diffs = append(diffs, d)
+		}
+	}
+
+	return diffs, nil
+}
+
+// ComputeResourceListsWithDrift is like ComputeResourceLists but, for
+// resources present in both lists, re-fetches the current side live from
+// the cluster first and produces a three-way, provenance-tagged diff via
+// ComputeDrift: hunks are tagged ProvenanceDrift where the live object
+// already deviated from what Helm last applied (current), independently of
+// the change being previewed, and ProvenanceIntended for the rest.
+func ComputeResourceListsWithDrift(current, target kube.ResourceList) ([]ResourceDiff, error) {
+	currentByKey := make(map[string]*resource.Info, len(current))
+	for _, info := range current {
+		currentByKey[infoName(info)] = info
+	}
+	targetByKey := make(map[string]*resource.Info, len(target))
+	for _, info := range target {
+		targetByKey[infoName(info)] = info
+	}
+
+	var diffs []ResourceDiff
+	for _, info := range target {
+		key := infoName(info)
+		old, ok := currentByKey[key]
+		if !ok {
+			d, err := diffOne(info, nil, info.Object, OpCreated)
+			if err != nil {
+				return nil, err
+			}
+			diffs = append(diffs, d)
+			continue
+		}
+
+		lastApplied := old.Object
+		if err := old.Get(); err != nil {
+			return nil, errors.Wrapf(err, "unable to fetch live state of %s/%s", old.Namespace, old.Name)
+		}
+		d, err := ComputeDrift(info, lastApplied, old.Object, info.Object)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, d)
+	}
+	for _, info := range current {
+		key := infoName(info)
+		if _, ok := targetByKey[key]; !ok {
+			d, err := diffOne(info, info.Object, nil, OpDeleted)
+			if err != nil {
+				return nil, err
+			}
+			diffs = append(diffs, d)
+		}
+	}
+
+	return diffs, nil
+}
+
+// ComputeDrift produces a three-way diff for a single resource between the
+// last-applied configuration, its live state, and the target manifest. The
+// hunks themselves are the full set of differences between lastApplied and
+// target -- everything Helm's rollback/upgrade is about to change, whether
+// or not that field also drifted -- and each changed line is additionally
+// tagged with its Provenance: ProvenanceDrift if the live object already
+// deviated from lastApplied independently of the change Helm is making,
+// ProvenanceIntended otherwise.
+//
+// Provenance is assigned by matching changed line text between the
+// lastApplied-vs-target hunks and a separate lastApplied-vs-live diff,
+// consuming each drifted line at most once; this is a best-effort heuristic
+// rather than a positional merge, so a hunk with several identical changed
+// lines (e.g. the same "protocol: TCP" line under several ports of one
+// Service) can still misattribute which specific occurrence was
+// drift vs. intended, though never more drifted occurrences than were
+// actually observed.
+func ComputeDrift(info *resource.Info, lastApplied, live, target interface{}) (ResourceDiff, error) {
+	lastLines, err := marshalLines(lastApplied)
+	if err != nil {
+		return ResourceDiff{}, err
+	}
+	liveLines, err := marshalLines(live)
+	if err != nil {
+		return ResourceDiff{}, err
+	}
+	targetLines, err := marshalLines(target)
+	if err != nil {
+		return ResourceDiff{}, err
+	}
+
+	driftCounts := changedLineCounts(Myers(lastLines, liveLines))
+
+	hunks := groupHunks(Myers(lastLines, targetLines))
+	for hi := range hunks {
+		for li := range hunks[hi].Lines {
+			line := &hunks[hi].Lines[li]
+			if line.Op == OpEqual {
+				continue
+			}
+			if driftCounts[line.Text] > 0 {
+				line.Provenance = ProvenanceDrift
+				driftCounts[line.Text]--
+			} else {
+				line.Provenance = ProvenanceIntended
+			}
+		}
+	}
+
+	return ResourceDiff{
+		GVK:       info.Mapping.GroupVersionKind,
+		Namespace: info.Namespace,
+		Name:      info.Name,
+		Op:        OpUpdated,
+		Hunks:     hunks,
+	}, nil
+}
+
+// changedLineCounts tallies how many times each distinct line text was
+// changed (inserted or deleted), so callers can consume one occurrence per
+// match instead of treating a single changed line as marking every
+// occurrence of that text as changed.
+func changedLineCounts(edits []Edit) map[string]int {
+	counts := make(map[string]int, len(edits))
+	for _, e := range edits {
+		if e.Op != OpEqual {
+			counts[e.Text]++
+		}
+	}
+	return counts
+}
+
+func diffOne(info *resource.Info, oldObj, newObj interface{}, op Op) (ResourceDiff, error) {
+	oldLines, err := marshalLines(oldObj)
+	if err != nil {
+		return ResourceDiff{}, err
+	}
+	newLines, err := marshalLines(newObj)
+	if err != nil {
+		return ResourceDiff{}, err
+	}
+
+	return ResourceDiff{
+		GVK:       info.Mapping.GroupVersionKind,
+		Namespace: info.Namespace,
+		Name:      info.Name,
+		Op:        op,
+		Hunks:     groupHunks(Myers(oldLines, newLines)),
+	}, nil
+}
+
+func marshalLines(obj interface{}) ([]string, error) {
+	if obj == nil {
+		return nil, nil
+	}
+	b, err := yaml.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	s := strings.TrimSuffix(string(b), "\n")
+	if s == "" {
+		return nil, nil
+	}
+	return strings.Split(s, "\n"), nil
+}
+
+func infoName(info *resource.Info) string {
+	return fmt.Sprintf("%s/%s/%s", info.Namespace, info.Mapping.GroupVersionKind.String(), info.Name)
+}
+
+// posEdit pairs an Edit with its 1-based line numbers in the old and/or new
+// sequence, so hunks can be grouped and numbered without re-scanning.
+type posEdit struct {
+	Edit
+	oldNum int
+	newNum int
+}
+
+func annotate(edits []Edit) []posEdit {
+	out := make([]posEdit, len(edits))
+	oldLine, newLine := 1, 1
+	for i, e := range edits {
+		p := posEdit{Edit: e}
+		switch e.Op {
+		case OpEqual:
+			p.oldNum, p.newNum = oldLine, newLine
+			oldLine++
+			newLine++
+		case OpDelete:
+			p.oldNum = oldLine
+			oldLine++
+		case OpInsert:
+			p.newNum = newLine
+			newLine++
+		}
+		out[i] = p
+	}
+	return out
+}
+
+func groupHunks(edits []Edit) []Hunk {
+	if len(edits) == 0 {
+		return nil
+	}
+	pe := annotate(edits)
+
+	var hunks []Hunk
+	i := 0
+	for i < len(pe) {
+		if pe[i].Op == OpEqual {
+			i++
+			continue
+		}
+
+		start, end := i, i
+		for {
+			j := end
+			for j < len(pe) && pe[j].Op != OpEqual {
+				j++
+			}
+			end = j
+
+			k := end
+			for k < len(pe) && pe[k].Op == OpEqual {
+				k++
+			}
+			if k < len(pe) && k-end < 2*contextLines {
+				end = k
+				continue
+			}
+			break
+		}
+
+		ctxStart := start
+		for n := 0; n < contextLines && ctxStart > 0; n++ {
+			ctxStart--
+		}
+		ctxEnd := end
+		for n := 0; n < contextLines && ctxEnd < len(pe); n++ {
+			ctxEnd++
+		}
+
+		hunks = append(hunks, buildHunk(pe[ctxStart:ctxEnd]))
+		i = end
+	}
+
+	return hunks
+}
+
+func buildHunk(pe []posEdit) Hunk {
+	h := Hunk{Lines: make([]HunkLine, 0, len(pe))}
+	for _, p := range pe {
+		h.Lines = append(h.Lines, HunkLine{Op: p.Op, Text: p.Text})
+		switch p.Op {
+		case OpEqual:
+			if h.OldStart == 0 {
+				h.OldStart, h.NewStart = p.oldNum, p.newNum
+			}
+			h.OldLines++
+			h.NewLines++
+		case OpDelete:
+			if h.OldStart == 0 {
+				h.OldStart = p.oldNum
+			}
+			h.OldLines++
+		case OpInsert:
+			if h.NewStart == 0 {
+				h.NewStart = p.newNum
+			}
+			h.NewLines++
+		}
+	}
+	return h
+}
@@ -0,0 +1,117 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diffutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+func TestGroupHunksMergesNearbyChanges(t *testing.T) {
+	is := assert.New(t)
+
+	a := []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}
+	b := []string{"1", "2", "3", "4", "X", "6", "7", "8", "9", "Y"}
+
+	hunks := groupHunks(Myers(a, b))
+
+	// The two changes are close enough (fewer than 2*context lines apart)
+	// that they should be merged into a single hunk rather than two.
+	is.Len(hunks, 1)
+	is.Equal(2, hunks[0].OldStart)
+	is.Equal(9, hunks[0].OldLines)
+}
+
+func TestChangedLineCounts(t *testing.T) {
+	is := assert.New(t)
+
+	edits := Myers([]string{"foo", "bar", "bar"}, []string{"foo", "baz", "bar"})
+	counts := changedLineCounts(edits)
+
+	is.Equal(1, counts["bar"])
+	is.Equal(1, counts["baz"])
+	is.Equal(0, counts["foo"])
+}
+
+func TestComputeDriftTagsProvenance(t *testing.T) {
+	is := assert.New(t)
+
+	info := &resource.Info{
+		Namespace: "default",
+		Name:      "web",
+		Mapping:   &meta.RESTMapping{GroupVersionKind: schema.GroupVersionKind{Kind: "ConfigMap"}},
+	}
+
+	lastApplied := map[string]interface{}{"data": map[string]interface{}{"a": "1", "b": "1"}}
+	// live drifted independently on "b" (e.g. a mutating webhook), while the
+	// rollback itself intends to change "a".
+	live := map[string]interface{}{"data": map[string]interface{}{"a": "1", "b": "2"}}
+	target := map[string]interface{}{"data": map[string]interface{}{"a": "3", "b": "2"}}
+
+	d, err := ComputeDrift(info, lastApplied, live, target)
+	is.NoError(err)
+
+	var sawDrift, sawIntended bool
+	for _, h := range d.Hunks {
+		for _, l := range h.Lines {
+			switch l.Provenance {
+			case ProvenanceDrift:
+				sawDrift = true
+			case ProvenanceIntended:
+				sawIntended = true
+			}
+		}
+	}
+	is.True(sawDrift, "b's independent drift should be tagged")
+	is.True(sawIntended, "a's rollback-driven change should be tagged")
+}
+
+func TestRenderText(t *testing.T) {
+	is := assert.New(t)
+
+	diffs := []ResourceDiff{
+		{
+			Namespace: "default",
+			Name:      "web",
+			Op:        OpUpdated,
+			Hunks: []Hunk{
+				{
+					OldStart: 1, OldLines: 1, NewStart: 1, NewLines: 1,
+					Lines: []HunkLine{{Op: OpDelete, Text: "replicas: 1"}, {Op: OpInsert, Text: "replicas: 2"}},
+				},
+			},
+		},
+	}
+
+	out := RenderText(diffs)
+	is.Contains(out, "replicas: 1")
+	is.Contains(out, "replicas: 2")
+}
+
+func TestRenderJSON(t *testing.T) {
+	is := assert.New(t)
+
+	diffs := []ResourceDiff{{Namespace: "default", Name: "web", Op: OpCreated}}
+
+	b, err := RenderJSON(diffs)
+	is.NoError(err)
+	is.Contains(string(b), `"name": "web"`)
+}
@@ -23,7 +23,11 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
 
+	"helm.sh/helm/v3/internal/diffutil"
 	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/kube"
 	"helm.sh/helm/v3/pkg/release"
@@ -49,6 +53,49 @@ type Rollback struct {
 	// ServerDryRun specifies whether to make use of apiserver dry-run for kube api.
 	// If `true`, the upgrade is performed with apiserver dry-run enabled, without changing stored release meta.
 	ServerDryRun bool
+
+	// Diffs holds the structured diff computed for the most recent DryRun,
+	// in rollback (target vs. current) order. It is populated only when
+	// DryRun is set.
+	Diffs []diffutil.ResourceDiff
+
+	// Atomic, if true, causes performRollback to automatically revert back
+	// to the pre-rollback live state when Wait/WaitForJobs times out,
+	// VerifyDrift detects drift, or a post-rollback hook fails, mirroring
+	// Upgrade.Atomic.
+	Atomic bool
+
+	// WaitStrategy, if set, takes precedence over Wait/WaitForJobs and
+	// controls how performRollback waits for the rolled-back resources to
+	// become ready. When unset, it is derived from Wait/WaitForJobs for
+	// backward compatibility.
+	WaitStrategy kube.WaitStrategy
+	// ProgressSink, if set, receives progress events from WaitStrategy.
+	ProgressSink kube.ProgressSink
+
+	// VerifyDrift, if true, re-fetches each rolled-back resource after Wait
+	// completes and fails the rollback if its live state (modulo
+	// server-managed fields) no longer matches what was applied -- catching
+	// admission webhooks or mutating controllers that silently altered it.
+	VerifyDrift bool
+	// PostApplyDrift holds any drift detected by VerifyDrift, one entry per
+	// resource that no longer matches what was applied.
+	PostApplyDrift []diffutil.ResourceDiff
+}
+
+// waitStrategy returns the configured WaitStrategy, or a strategy derived
+// from the legacy Wait/WaitForJobs booleans if none was set.
+func (r *Rollback) waitStrategy() kube.WaitStrategy {
+	if r.WaitStrategy != nil {
+		return r.WaitStrategy
+	}
+	if !r.Wait {
+		return kube.NoWait{}
+	}
+	if r.WaitForJobs {
+		return kube.JobsWait{}
+	}
+	return kube.LegacyWait{}
 }
 
 // NewRollback creates a new Rollback object with the given configuration.
@@ -160,10 +207,7 @@ func (r *Rollback) prepareRollback(name string) (*release.Release, *release.Rele
 }
 
 func (r *Rollback) performRollback(currentRelease, targetRelease *release.Release) (*release.Release, *kube.Result, error) {
-	if r.DryRun {
-		r.cfg.Log("dry run for %s", targetRelease.Name)
-		return targetRelease, nil, nil
-	}
+	start := time.Now()
 
 	current, err := r.cfg.KubeClient.Build(bytes.NewBufferString(currentRelease.Manifest), false)
 	if err != nil {
@@ -174,6 +218,17 @@ func (r *Rollback) performRollback(currentRelease, targetRelease *release.Releas
 		return targetRelease, nil, errors.Wrap(err, "unable to build kubernetes objects from new release manifest")
 	}
 
+	if r.DryRun {
+		r.cfg.Log("dry run for %s", targetRelease.Name)
+		diffs, err := diffutil.ComputeResourceListsWithDrift(current, target)
+		if err != nil {
+			return targetRelease, nil, errors.Wrap(err, "unable to compute rollback diff")
+		}
+		r.Diffs = diffs
+		r.cfg.Log("%s", diffutil.RenderText(diffs))
+		return targetRelease, nil, nil
+	}
+
 	// pre-rollback hooks
 	if !r.ServerDryRun {
 		if !r.DisableHooks {
@@ -227,27 +282,61 @@ func (r *Rollback) performRollback(currentRelease, targetRelease *release.Releas
 		}
 	}
 
-	if r.Wait {
-		if r.WaitForJobs {
-			if err := r.cfg.KubeClient.WaitWithJobs(target, r.Timeout); err != nil {
-				targetRelease.SetStatus(release.StatusFailed, fmt.Sprintf("Release %q failed: %s", targetRelease.Name, err.Error()))
-				r.cfg.recordRelease(currentRelease)
-				r.cfg.recordRelease(targetRelease)
-				return targetRelease, nil, errors.Wrapf(err, "release %s failed", targetRelease.Name)
+	// When Atomic, Timeout is a total budget for forward wait plus a
+	// possible revert, not a per-phase allowance: give the forward wait only
+	// half of it so a revert always has its own share left, rather than
+	// starting only once the whole budget is already spent.
+	forwardTimeout := r.Timeout
+	if r.Atomic && r.Timeout > 0 {
+		forwardTimeout = r.Timeout / 2
+	}
+
+	if err := r.waitStrategy().Wait(r.cfg.KubeClient, target, forwardTimeout, r.ProgressSink); err != nil {
+		waitErr := errors.Wrapf(err, "release %s failed", targetRelease.Name)
+		if r.Atomic {
+			return r.revertRollback(currentRelease, targetRelease, results, start, waitErr)
+		}
+		targetRelease.SetStatus(release.StatusFailed, fmt.Sprintf("Release %q failed: %s", targetRelease.Name, err.Error()))
+		r.cfg.recordRelease(currentRelease)
+		r.cfg.recordRelease(targetRelease)
+		return targetRelease, nil, waitErr
+	}
+
+	if r.VerifyDrift {
+		drift, err := r.verifyDrift(target, targetRelease)
+		if err != nil {
+			return targetRelease, nil, errors.Wrap(err, "unable to verify rollback applied drift")
+		}
+		r.PostApplyDrift = drift
+		if len(drift) > 0 {
+			driftErr := fmt.Errorf("detected drift between applied and live state for %d resource(s) after rollback", len(drift))
+			if r.Atomic {
+				return r.revertRollback(currentRelease, targetRelease, results, start, driftErr)
 			}
-		} else {
-			if err := r.cfg.KubeClient.Wait(target, r.Timeout); err != nil {
-				targetRelease.SetStatus(release.StatusFailed, fmt.Sprintf("Release %q failed: %s", targetRelease.Name, err.Error()))
-				r.cfg.recordRelease(currentRelease)
-				r.cfg.recordRelease(targetRelease)
-				return targetRelease, nil, errors.Wrapf(err, "release %s failed", targetRelease.Name)
+			targetRelease.SetStatus(release.StatusFailed, fmt.Sprintf("Release %q failed: %s", targetRelease.Name, driftErr))
+			r.cfg.recordRelease(currentRelease)
+			r.cfg.recordRelease(targetRelease)
+			if r.CleanupOnFail {
+				r.cfg.Log("Cleanup on fail set, cleaning up %d resources", len(results.Created))
+				if _, errs := r.cfg.KubeClient.Delete(results.Created); errs != nil {
+					var errorList []string
+					for _, e := range errs {
+						errorList = append(errorList, e.Error())
+					}
+					return targetRelease, nil, errors.Wrapf(fmt.Errorf("unable to cleanup resources: %s", strings.Join(errorList, ", ")), "an error occurred while cleaning up resources. original rollback error: %s", driftErr)
+				}
+				r.cfg.Log("Resource cleanup complete")
 			}
+			return targetRelease, nil, driftErr
 		}
 	}
 
 	// post-rollback hooks
 	if !r.DisableHooks {
 		if err := r.cfg.execHook(targetRelease, release.HookPostRollback, r.Timeout); err != nil {
+			if r.Atomic {
+				return r.revertRollback(currentRelease, targetRelease, results, start, err)
+			}
 			return targetRelease, nil, err
 		}
 	}
@@ -267,3 +356,197 @@ func (r *Rollback) performRollback(currentRelease, targetRelease *release.Releas
 
 	return targetRelease, results, nil
 }
+
+// verifyDrift re-fetches each object in target and diffs it against what
+// was applied, returning one ResourceDiff per resource that drifted. It is
+// only meaningful once KubeClient.Update has landed target and Wait (if
+// any) has completed.
+//
+// The desired side of the diff is rebuilt from targetRelease.Manifest
+// rather than read off target's *resource.Info objects: KubeClient.Update
+// already refreshed those in place to the server's response (post-admission,
+// post-defaulting), so diffing that against another live fetch would compare
+// the mutated object against itself and never see a webhook or controller's
+// changes -- exactly the case VerifyDrift exists to catch.
+func (r *Rollback) verifyDrift(target kube.ResourceList, targetRelease *release.Release) ([]diffutil.ResourceDiff, error) {
+	desired, err := r.cfg.KubeClient.Build(bytes.NewBufferString(targetRelease.Manifest), false)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to rebuild target manifest for drift verification")
+	}
+
+	var drifts []diffutil.ResourceDiff
+	for _, info := range target {
+		desiredInfo := desired.Get(info)
+		if desiredInfo == nil {
+			return nil, fmt.Errorf("no manifest entry for %s/%s during drift verification", info.Namespace, info.Name)
+		}
+		d, err := diffutil.ComputeAppliedDrift(info, desiredInfo.Object)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to verify %s/%s", info.Namespace, info.Name)
+		}
+		if len(d.Hunks) > 0 {
+			drifts = append(drifts, d)
+		}
+	}
+	return drifts, nil
+}
+
+// revertRollback is invoked when Atomic is set and the forward rollback
+// failed after KubeClient.Update already landed the target manifest (a
+// failed Wait/WaitForJobs, drift detected by VerifyDrift, or a failing
+// post-rollback hook). It drives the
+// cluster back to the state it observed live just before the forward
+// update (results.LiveBeforeUpdate), deletes anything the forward rollback
+// created, and records a new release revision describing the revert. The
+// original failure (cause) is always returned so callers still see why the
+// rollback itself failed.
+func (r *Rollback) revertRollback(currentRelease, targetRelease *release.Release, results *kube.Result, start time.Time, cause error) (*release.Release, *kube.Result, error) {
+	msg := fmt.Sprintf("Rollback %q failed: %s -- auto rollback successful", targetRelease.Name, cause)
+	r.cfg.Log("warning: %s", fmt.Sprintf("Rollback %q failed (%s), reverting to pre-rollback state", targetRelease.Name, cause))
+
+	revertManifest, err := manifestFromLiveBeforeUpdate(results)
+	if err != nil {
+		return targetRelease, nil, errors.Wrap(err, "unable to reconstruct pre-rollback state for automatic revert")
+	}
+
+	revertTimeout := remainingTimeout(r.Timeout, start)
+
+	target, err := r.cfg.KubeClient.Build(bytes.NewBufferString(targetRelease.Manifest), false)
+	if err != nil {
+		return targetRelease, nil, errors.Wrap(err, "unable to build kubernetes objects from rolled-back release manifest")
+	}
+	revertTarget, err := r.cfg.KubeClient.Build(bytes.NewBufferString(revertManifest), false)
+	if err != nil {
+		return targetRelease, nil, errors.Wrap(err, "unable to build kubernetes objects from pre-rollback state")
+	}
+
+	if !r.DisableHooks {
+		if err := r.cfg.execHook(currentRelease, release.HookPreRollback, revertTimeout); err != nil {
+			return targetRelease, nil, errors.Wrapf(err, "rollback %q failed (%s) and the automatic revert's pre-rollback hook also failed", targetRelease.Name, cause)
+		}
+	}
+
+	// revertManifest omits resources the forward rollback created (they have
+	// no pre-rollback live state to revert to), so revertTarget doesn't
+	// either; Update's own prune of anything present in target but absent
+	// from revertTarget is what removes them. Don't also delete them here --
+	// results.Created is already gone from the cluster by the time Update
+	// returns, and a second Delete against it only returns spurious
+	// NotFound errors.
+	revertResults, err := r.cfg.KubeClient.Update(target, revertTarget, r.Force)
+	if err != nil {
+		return targetRelease, nil, errors.Wrapf(err, "rollback %q failed (%s) and the automatic revert also failed", targetRelease.Name, cause)
+	}
+
+	if err := r.waitStrategy().Wait(r.cfg.KubeClient, revertTarget, revertTimeout, r.ProgressSink); err != nil {
+		return targetRelease, nil, errors.Wrapf(cause, "rollback failed and the automatic revert did not become ready: %s", err)
+	}
+
+	if !r.DisableHooks {
+		if err := r.cfg.execHook(currentRelease, release.HookPostRollback, revertTimeout); err != nil {
+			return targetRelease, nil, errors.Wrapf(err, "rollback %q failed (%s) and the automatic revert's post-rollback hook also failed", targetRelease.Name, cause)
+		}
+	}
+
+	targetRelease.Info.Status = release.StatusFailed
+	targetRelease.Info.Description = msg
+	r.cfg.recordRelease(targetRelease)
+
+	deployed, err := r.cfg.Releases.DeployedAll(currentRelease.Name)
+	if err != nil && !strings.Contains(err.Error(), "has no deployed releases") {
+		return targetRelease, nil, errors.Wrap(err, "unable to look up deployed releases during automatic revert")
+	}
+	// Supersede currentRelease (and any other still-Deployed release) so the
+	// revert we're about to record is the only Deployed revision, mirroring
+	// the successful-rollback path in performRollback.
+	for _, rel := range deployed {
+		r.cfg.Log("superseding previous deployment %d", rel.Version)
+		rel.Info.Status = release.StatusSuperseded
+		r.cfg.recordRelease(rel)
+	}
+
+	revertRelease := &release.Release{
+		Name:      targetRelease.Name,
+		Namespace: targetRelease.Namespace,
+		Chart:     currentRelease.Chart,
+		Config:    currentRelease.Config,
+		Info: &release.Info{
+			FirstDeployed: currentRelease.Info.FirstDeployed,
+			LastDeployed:  helmtime.Now(),
+			Status:        release.StatusDeployed,
+			Notes:         currentRelease.Info.Notes,
+			Description:   fmt.Sprintf("Auto rollback to %d after failed rollback to %d", currentRelease.Version, targetRelease.Version),
+		},
+		Version:  targetRelease.Version + 1,
+		Manifest: revertManifest,
+		Hooks:    currentRelease.Hooks,
+	}
+	if err := r.cfg.Releases.Create(revertRelease); err != nil {
+		return targetRelease, nil, errors.Wrap(err, "unable to record automatic revert release")
+	}
+
+	return targetRelease, revertResults, errors.Wrapf(cause, "rollback %q failed and was automatically reverted", targetRelease.Name)
+}
+
+// manifestFromLiveBeforeUpdate reconstructs a release manifest for the
+// state before the forward rollback's update: resources the forward
+// rollback updated are restored to their pre-update live content, and
+// resources it deleted are re-added so the revert recreates them. Deleted
+// resources are taken from their object in results.Deleted (built from the
+// current release manifest), not from a live fetch, since Update does not
+// record their live state before deleting them. Resources the forward
+// rollback created have no prior live state and are intentionally omitted; Update's own prune
+// removes them once the revert is applied (see revertRollback). Fields
+// Kubernetes populates server-side (resourceVersion, uid, status, ...) are
+// stripped first -- reapplying them verbatim risks resourceVersion-conflict
+// failures and pushes server-owned fields Helm shouldn't be setting.
+func manifestFromLiveBeforeUpdate(results *kube.Result) (string, error) {
+	marshalSanitized := func(obj runtime.Object) (string, error) {
+		m, err := diffutil.ToUnstructuredMap(obj)
+		if err != nil {
+			return "", err
+		}
+		diffutil.StripServerManagedFields(m)
+		b, err := yaml.Marshal(&unstructured.Unstructured{Object: m})
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+
+	var docs []string
+	for _, info := range results.Updated {
+		live := results.LiveBeforeUpdate[info]
+		if live == nil {
+			return "", fmt.Errorf("no live-before-update version of %s/%s", info.Namespace, info.Name)
+		}
+		doc, err := marshalSanitized(live)
+		if err != nil {
+			return "", err
+		}
+		docs = append(docs, doc)
+	}
+	for _, info := range results.Deleted {
+		doc, err := marshalSanitized(info.Object)
+		if err != nil {
+			return "", err
+		}
+		docs = append(docs, doc)
+	}
+	return strings.Join(docs, "---\n"), nil
+}
+
+// remainingTimeout splits a total timeout budget between the forward and
+// revert phases of an atomic rollback: whatever is left of timeout after
+// start is handed to the revert, falling back to half of the original
+// budget if the forward phase already consumed (or overran) all of it.
+func remainingTimeout(timeout time.Duration, start time.Time) time.Duration {
+	if timeout <= 0 {
+		return timeout
+	}
+	remaining := timeout - time.Since(start)
+	if remaining <= 0 {
+		return timeout / 2
+	}
+	return remaining
+}
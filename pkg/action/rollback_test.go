@@ -0,0 +1,94 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	"helm.sh/helm/v3/pkg/kube"
+)
+
+func TestRemainingTimeout(t *testing.T) {
+	is := assert.New(t)
+
+	// No budget set: nothing to split, pass the zero value through.
+	is.Equal(time.Duration(0), remainingTimeout(0, time.Now()))
+
+	// Budget not yet exhausted: the revert gets whatever is left.
+	start := time.Now().Add(-10 * time.Second)
+	remaining := remainingTimeout(time.Minute, start)
+	is.Greater(remaining, time.Duration(0))
+	is.Less(remaining, time.Minute)
+
+	// Budget already exhausted by the forward phase: fall back to half of
+	// the original budget rather than leaving the revert with no time.
+	start = time.Now().Add(-time.Hour)
+	is.Equal(30*time.Second, remainingTimeout(time.Minute, start))
+}
+
+func TestManifestFromLiveBeforeUpdateSanitizesAndOmitsCreated(t *testing.T) {
+	is := assert.New(t)
+
+	updatedInfo := &resource.Info{Namespace: "default", Name: "web"}
+	deletedInfo := &resource.Info{
+		Namespace: "default",
+		Name:      "stale",
+		Object: &unstructured.Unstructured{Object: map[string]interface{}{
+			"metadata": map[string]interface{}{"name": "stale"},
+		}},
+	}
+	// createdInfo is deliberately left out of results.Updated/Deleted and
+	// out of LiveBeforeUpdate: manifestFromLiveBeforeUpdate must not
+	// reference it, since a forward-created resource has no prior live
+	// state to revert to.
+	createdInfo := &resource.Info{Namespace: "default", Name: "new"}
+
+	live := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":            "web",
+			"resourceVersion": "123",
+			"uid":             "abc-def",
+			"managedFields":   []interface{}{map[string]interface{}{"manager": "helm"}},
+		},
+		"status": map[string]interface{}{"phase": "Running"},
+	}}
+
+	results := &kube.Result{
+		Created: kube.ResourceList{createdInfo},
+		Updated: kube.ResourceList{updatedInfo},
+		Deleted: kube.ResourceList{deletedInfo},
+		LiveBeforeUpdate: map[*resource.Info]runtime.Object{
+			updatedInfo: live,
+		},
+	}
+
+	manifest, err := manifestFromLiveBeforeUpdate(results)
+	is.NoError(err)
+	is.Contains(manifest, "name: web")
+	is.Contains(manifest, "name: stale")
+	is.NotContains(manifest, "name: new")
+	is.NotContains(manifest, "resourceVersion")
+	is.NotContains(manifest, "uid:")
+	is.NotContains(manifest, "managedFields")
+	is.NotContains(manifest, "status:")
+}
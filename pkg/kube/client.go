@@ -0,0 +1,272 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+// Interface abstracts applying a release's resources to a Kubernetes
+// cluster, so pkg/action can be exercised against a fake in tests.
+type Interface interface {
+	Build(reader io.Reader, validate bool) (ResourceList, error)
+	Update(original, target ResourceList, force bool) (*Result, error)
+	Delete(resources ResourceList) (*Result, []error)
+	Wait(resources ResourceList, timeout time.Duration) error
+	WaitWithJobs(resources ResourceList, timeout time.Duration) error
+	IsReachable() error
+}
+
+// ServerDryRunnableInterface is an Interface that can be switched into
+// apiserver dry-run mode, where mutating calls are sent with dry-run
+// semantics and never persisted.
+type ServerDryRunnableInterface interface {
+	Interface
+	WithServerDryRun() Interface
+}
+
+// Client is the default Interface implementation: it builds resources via
+// a resource.Builder and applies them through each resource's own REST
+// client.
+type Client struct {
+	Factory genericclioptions.RESTClientGetter
+	DryRun  bool
+}
+
+// WithServerDryRun implements ServerDryRunnableInterface, returning a Client
+// whose mutating calls carry apiserver dry-run semantics.
+func (c *Client) WithServerDryRun() Interface {
+	dr := *c
+	dr.DryRun = true
+	return &dr
+}
+
+// Build implements Interface.
+func (c *Client) Build(reader io.Reader, validate bool) (ResourceList, error) {
+	result := resource.NewBuilder(c.Factory).
+		Unstructured().
+		ContinueOnError().
+		Stream(reader, "").
+		Flatten().
+		Do()
+	if validate {
+		result = result.Validate()
+	}
+	if err := result.Err(); err != nil {
+		return nil, errors.Wrap(err, "unable to build kubernetes objects from release manifest")
+	}
+	infos, err := result.Infos()
+	if err != nil {
+		return nil, err
+	}
+	return ResourceList(infos), nil
+}
+
+// helperOps is the subset of *resource.Helper's behavior Update needs. It
+// exists so tests can substitute a fake instead of talking to a real
+// cluster.
+type helperOps interface {
+	Get(namespace, name string) (runtime.Object, error)
+	Create(namespace string, modify bool, obj runtime.Object) (runtime.Object, error)
+	Replace(namespace, name string, overwrite bool, obj runtime.Object) (runtime.Object, error)
+	Delete(namespace, name string) (runtime.Object, error)
+}
+
+// newHelper constructs the helperOps Update and Delete use to talk to the
+// cluster for a given resource; a var so tests can substitute a fake.
+var newHelper = func(info *resource.Info) helperOps {
+	return resource.NewHelper(info.Client, info.Mapping)
+}
+
+// Update implements Interface. It reconciles the cluster to match target:
+// resources in target that don't yet exist in the cluster are created,
+// those that do exist are replaced in place (deleted and recreated if force
+// is set and the replace is rejected), and resources present in original
+// but not target are deleted. For every replaced resource, its live state is
+// fetched and recorded in Result.LiveBeforeUpdate before the replace is
+// applied, so callers -- e.g. an atomic rollback's automatic revert -- can
+// restore it.
+func (c *Client) Update(original, target ResourceList, force bool) (*Result, error) {
+	res := &Result{LiveBeforeUpdate: map[*resource.Info]runtime.Object{}}
+
+	for _, info := range target {
+		helper := newHelper(info)
+
+		live, err := helper.Get(info.Namespace, info.Name)
+		if err != nil {
+			if !apierrors.IsNotFound(err) {
+				return res, errors.Wrapf(err, "unable to get %s/%s", info.Namespace, info.Name)
+			}
+			obj, err := helper.Create(info.Namespace, true, info.Object)
+			if err != nil {
+				return res, errors.Wrapf(err, "unable to create %s/%s", info.Namespace, info.Name)
+			}
+			info.Object = obj
+			res.Created = append(res.Created, info)
+			continue
+		}
+
+		res.LiveBeforeUpdate[info] = live.DeepCopyObject()
+
+		obj, err := helper.Replace(info.Namespace, info.Name, true, info.Object)
+		if err != nil {
+			if !force {
+				return res, errors.Wrapf(err, "unable to update %s/%s", info.Namespace, info.Name)
+			}
+			if _, err := helper.Delete(info.Namespace, info.Name); err != nil && !apierrors.IsNotFound(err) {
+				return res, errors.Wrapf(err, "unable to delete %s/%s for forced update", info.Namespace, info.Name)
+			}
+			if obj, err = helper.Create(info.Namespace, true, info.Object); err != nil {
+				return res, errors.Wrapf(err, "unable to recreate %s/%s for forced update", info.Namespace, info.Name)
+			}
+		}
+		info.Object = obj
+		res.Updated = append(res.Updated, info)
+	}
+
+	for _, info := range original {
+		if target.Get(info) != nil {
+			continue
+		}
+		helper := newHelper(info)
+		if _, err := helper.Delete(info.Namespace, info.Name); err != nil && !apierrors.IsNotFound(err) {
+			return res, errors.Wrapf(err, "unable to delete %s/%s", info.Namespace, info.Name)
+		}
+		res.Deleted = append(res.Deleted, info)
+	}
+
+	return res, nil
+}
+
+// Delete implements Interface.
+func (c *Client) Delete(resources ResourceList) (*Result, []error) {
+	res := &Result{}
+	var errs []error
+	for _, info := range resources {
+		helper := newHelper(info)
+		if _, err := helper.Delete(info.Namespace, info.Name); err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, errors.Wrapf(err, "unable to delete %s/%s", info.Namespace, info.Name))
+			continue
+		}
+		res.Deleted = append(res.Deleted, info)
+	}
+	return res, errs
+}
+
+// Wait implements Interface.
+func (c *Client) Wait(resources ResourceList, timeout time.Duration) error {
+	return pollReady(resources, timeout, false)
+}
+
+// WaitWithJobs implements Interface. It behaves like Wait but additionally
+// waits for Jobs to reach a Complete condition rather than considering them
+// ready as soon as they exist.
+func (c *Client) WaitWithJobs(resources ResourceList, timeout time.Duration) error {
+	return pollReady(resources, timeout, true)
+}
+
+// IsReachable implements Interface.
+func (c *Client) IsReachable() error {
+	discovery, err := c.Factory.ToDiscoveryClient()
+	if err != nil {
+		return errors.Wrap(err, "unable to build discovery client")
+	}
+	if _, err := discovery.ServerVersion(); err != nil {
+		return errors.Wrap(err, "Kubernetes cluster unreachable")
+	}
+	return nil
+}
+
+// pollReady polls resources until each looks healthy by a simple,
+// kind-agnostic heuristic (status.readyReplicas caught up to spec.replicas,
+// or -- when waitForJobs is set -- a Job's Complete/Failed condition), or
+// timeout elapses. This is deliberately simpler than a full
+// per-kind readiness evaluator; it covers the common Deployment/StatefulSet
+// and Job cases a rollback or upgrade waits on.
+func pollReady(resources ResourceList, timeout time.Duration, waitForJobs bool) error {
+	pending := map[*resource.Info]bool{}
+	for _, info := range resources {
+		pending[info] = true
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		for info := range pending {
+			if err := info.Get(); err != nil {
+				return errors.Wrapf(err, "unable to fetch %s/%s while waiting for readiness", info.Namespace, info.Name)
+			}
+			ready, err := isReady(info, waitForJobs)
+			if err != nil {
+				return err
+			}
+			if ready {
+				delete(pending, info)
+			}
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for %d resource(s) to become ready", len(pending))
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+func isReady(info *resource.Info, waitForJobs bool) (bool, error) {
+	obj, err := toUnstructuredMap(info.Object)
+	if err != nil {
+		return false, err
+	}
+	status, _ := obj["status"].(map[string]interface{})
+	if status == nil {
+		return true, nil
+	}
+
+	if waitForJobs && info.Mapping.GroupVersionKind.Kind == "Job" {
+		conditions, _ := status["conditions"].([]interface{})
+		for _, c := range conditions {
+			cond, _ := c.(map[string]interface{})
+			if cond["type"] == "Failed" && cond["status"] == "True" {
+				return false, fmt.Errorf("job %s/%s failed", info.Namespace, info.Name)
+			}
+			if cond["type"] == "Complete" && cond["status"] == "True" {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	readyReplicas, hasReadyReplicas := status["readyReplicas"]
+	if !hasReadyReplicas {
+		return true, nil
+	}
+	spec, _ := obj["spec"].(map[string]interface{})
+	wantReplicas := spec["replicas"]
+	return fmt.Sprintf("%v", readyReplicas) == fmt.Sprintf("%v", wantReplicas), nil
+}
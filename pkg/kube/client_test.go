@@ -0,0 +1,135 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeHelper is a helperOps double: Get returns the canned live object (or
+// a NotFound error), and Replace/Create/Delete record that they were called
+// (Replace also fails with replaceErr when it is set), so tests can assert
+// on Update's bookkeeping without a cluster.
+type fakeHelper struct {
+	live       runtime.Object
+	notFound   bool
+	replaceErr error
+
+	created, replaced, deleted bool
+}
+
+func (f *fakeHelper) Get(_, _ string) (runtime.Object, error) {
+	if f.notFound {
+		return nil, apierrors.NewNotFound(schema.GroupResource{}, "")
+	}
+	return f.live, nil
+}
+
+func (f *fakeHelper) Create(_ string, _ bool, obj runtime.Object) (runtime.Object, error) {
+	f.created = true
+	return obj, nil
+}
+
+func (f *fakeHelper) Replace(_, _ string, _ bool, obj runtime.Object) (runtime.Object, error) {
+	f.replaced = true
+	if f.replaceErr != nil {
+		return nil, f.replaceErr
+	}
+	return obj, nil
+}
+
+func (f *fakeHelper) Delete(_, _ string) (runtime.Object, error) {
+	f.deleted = true
+	return nil, nil
+}
+
+func withFakeHelper(t *testing.T, h *fakeHelper) {
+	t.Helper()
+	prev := newHelper
+	newHelper = func(*resource.Info) helperOps { return h }
+	t.Cleanup(func() { newHelper = prev })
+}
+
+func podInfo(name string) *resource.Info {
+	return &resource.Info{
+		Namespace: "default",
+		Name:      name,
+		Mapping:   &meta.RESTMapping{GroupVersionKind: schema.GroupVersionKind{Kind: "Pod"}},
+		Object: &unstructured.Unstructured{Object: map[string]interface{}{
+			"metadata": map[string]interface{}{"name": name},
+		}},
+	}
+}
+
+func TestUpdateRecordsLiveBeforeUpdate(t *testing.T) {
+	is := assert.New(t)
+
+	live := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "web", "resourceVersion": "42"},
+	}}
+	h := &fakeHelper{live: live}
+	withFakeHelper(t, h)
+
+	target := ResourceList{podInfo("web")}
+	c := &Client{}
+
+	res, err := c.Update(nil, target, false)
+	is.NoError(err)
+	is.True(h.replaced)
+	is.Len(res.Updated, 1)
+	is.Equal(live, res.LiveBeforeUpdate[target[0]])
+}
+
+func TestUpdateCreatesWhenNotFound(t *testing.T) {
+	is := assert.New(t)
+
+	h := &fakeHelper{notFound: true}
+	withFakeHelper(t, h)
+
+	target := ResourceList{podInfo("web")}
+	c := &Client{}
+
+	res, err := c.Update(nil, target, false)
+	is.NoError(err)
+	is.True(h.created)
+	is.Len(res.Created, 1)
+	is.Empty(res.LiveBeforeUpdate)
+}
+
+func TestUpdateDeletesResourcesMissingFromTarget(t *testing.T) {
+	is := assert.New(t)
+
+	h := &fakeHelper{notFound: true}
+	withFakeHelper(t, h)
+
+	original := ResourceList{podInfo("stale")}
+	c := &Client{}
+
+	res, err := c.Update(original, nil, false)
+	is.NoError(err)
+	is.True(h.deleted)
+	is.Len(res.Deleted, 1)
+}
@@ -0,0 +1,51 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+// ResourceList is a list of Kubernetes resources, built from a release
+// manifest or fetched from the cluster.
+type ResourceList []*resource.Info
+
+// Get returns the element of l matching info's namespace, name, and GVK, or
+// nil if none is found.
+func (l ResourceList) Get(info *resource.Info) *resource.Info {
+	for _, i := range l {
+		if i.Namespace == info.Namespace && i.Name == info.Name && i.Mapping.GroupVersionKind == info.Mapping.GroupVersionKind {
+			return i
+		}
+	}
+	return nil
+}
+
+// Result is the outcome of reconciling a ResourceList against the cluster
+// via Client.Update.
+type Result struct {
+	Created ResourceList
+	Updated ResourceList
+	Deleted ResourceList
+
+	// LiveBeforeUpdate holds, for each resource in Updated, its live state
+	// as fetched from the cluster immediately before Update patched it --
+	// the "old" side of a diff (Compute), and what an atomic rollback's
+	// automatic revert restores (manifestFromLiveBeforeUpdate).
+	LiveBeforeUpdate map[*resource.Info]runtime.Object
+}
@@ -0,0 +1,151 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+var podGVK = schema.GroupVersionKind{Version: "v1", Kind: "Pod"}
+
+func infoWithPhase(phase string) *resource.Info {
+	return &resource.Info{
+		Namespace: "default",
+		Name:      "web",
+		Mapping:   &meta.RESTMapping{GroupVersionKind: podGVK},
+		Object: &unstructured.Unstructured{Object: map[string]interface{}{
+			"status": map[string]interface{}{"phase": phase},
+		}},
+	}
+}
+
+func TestCompilePredicatesJSONPath(t *testing.T) {
+	is := assert.New(t)
+
+	byGVK, err := compilePredicates([]ConditionPredicate{
+		{GVK: podGVK, JSONPath: "{.status.phase}", Equals: "Running"},
+	})
+	is.NoError(err)
+	is.Len(byGVK[podGVK], 1)
+
+	ready, _, err := evaluatePredicates(infoWithPhase("Running"), byGVK[podGVK])
+	is.NoError(err)
+	is.True(ready)
+
+	ready, msg, err := evaluatePredicates(infoWithPhase("Pending"), byGVK[podGVK])
+	is.NoError(err)
+	is.False(ready)
+	is.Contains(msg, "status.phase")
+}
+
+func TestCompilePredicatesCEL(t *testing.T) {
+	is := assert.New(t)
+
+	byGVK, err := compilePredicates([]ConditionPredicate{
+		{GVK: podGVK, Expr: `object.status.phase == "Running"`},
+	})
+	is.NoError(err)
+	is.Len(byGVK[podGVK], 1)
+
+	ready, _, err := evaluatePredicates(infoWithPhase("Running"), byGVK[podGVK])
+	is.NoError(err)
+	is.True(ready)
+
+	ready, _, err = evaluatePredicates(infoWithPhase("Pending"), byGVK[podGVK])
+	is.NoError(err)
+	is.False(ready)
+}
+
+func TestCompilePredicatesInvalidCEL(t *testing.T) {
+	is := assert.New(t)
+
+	_, err := compilePredicates([]ConditionPredicate{
+		{GVK: podGVK, Expr: "object.status.phase =="},
+	})
+	is.Error(err)
+}
+
+func TestCompilePredicatesInvalidJSONPath(t *testing.T) {
+	is := assert.New(t)
+
+	_, err := compilePredicates([]ConditionPredicate{
+		{GVK: podGVK, JSONPath: "{.status.phase"},
+	})
+	is.Error(err)
+}
+
+func TestEvaluatePredicatesMultipleMustAllPass(t *testing.T) {
+	is := assert.New(t)
+
+	byGVK, err := compilePredicates([]ConditionPredicate{
+		{GVK: podGVK, JSONPath: "{.status.phase}", Equals: "Running"},
+		{GVK: podGVK, Expr: `object.status.phase == "Running"`},
+	})
+	is.NoError(err)
+
+	ready, _, err := evaluatePredicates(infoWithPhase("Running"), byGVK[podGVK])
+	is.NoError(err)
+	is.True(ready)
+}
+
+type stubWaitStrategy struct {
+	gotTimeout time.Duration
+	err        error
+}
+
+func (s *stubWaitStrategy) Wait(_ Interface, _ ResourceList, timeout time.Duration, _ ProgressSink) error {
+	s.gotTimeout = timeout
+	return s.err
+}
+
+func TestCompositeRunsEveryStrategy(t *testing.T) {
+	is := assert.New(t)
+
+	first := &stubWaitStrategy{}
+	second := &stubWaitStrategy{}
+	c := Composite{Strategies: []WaitStrategy{first, second}}
+
+	err := c.Wait(nil, nil, time.Minute, nil)
+	is.NoError(err)
+	is.Greater(first.gotTimeout, time.Duration(0))
+	is.Greater(second.gotTimeout, time.Duration(0))
+}
+
+func TestCompositeStopsOnError(t *testing.T) {
+	is := assert.New(t)
+
+	failErr := assert.AnError
+	first := &stubWaitStrategy{err: failErr}
+	second := &stubWaitStrategy{}
+	c := Composite{Strategies: []WaitStrategy{first, second}}
+
+	err := c.Wait(nil, nil, time.Minute, nil)
+	is.Equal(failErr, err)
+	is.Zero(second.gotTimeout, "second strategy must not run once the first fails")
+}
+
+func TestNoWaitReturnsImmediately(t *testing.T) {
+	is := assert.New(t)
+	is.NoError(NoWait{}.Wait(nil, nil, 0, nil))
+}